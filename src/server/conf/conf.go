@@ -19,13 +19,17 @@ type Filters struct {
 	Include []string `yaml:"include"`
 }
 
-type Conf struct {
-	ForTest struct {
-		Path string `yaml:"path"`
-	} `yaml:"for_test"`
-
+// Workspace is a single indexed directory, with its own filters and its own
+// isolated LevelDB instance keyed by Name.
+type Workspace struct {
+	Name    string  `yaml:"name"`
+	Path    string  `yaml:"path"`
 	Filters Filters `yaml:"filters"`
-	Port    int     `yaml:"port"`
+}
+
+type Conf struct {
+	Workspaces []Workspace `yaml:"workspaces"`
+	Port       int         `yaml:"port"`
 }
 
 var conf *Conf
@@ -0,0 +1,46 @@
+package document
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Content holds the parsed, searchable representation of a file.
+type Content struct {
+	Words []string
+}
+
+// Document is a single parsed, indexable file.
+type Document struct {
+	ID      string
+	Path    string
+	Content Content
+}
+
+// Parse reads the file at path and extracts its indexable content. Path is
+// stored relative to baseDir so that documents remain stable across moves
+// of the workspace itself.
+func Parse(path, baseDir string) (*Document, error) {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil {
+		rel = path
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	sum := sha256.Sum256([]byte(rel))
+	return &Document{
+		ID:   hex.EncodeToString(sum[:]),
+		Path: rel,
+		Content: Content{
+			Words: strings.Fields(string(data)),
+		},
+	}, nil
+}
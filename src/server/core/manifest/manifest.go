@@ -0,0 +1,43 @@
+// Package manifest records, per indexed file, the metadata the indexer
+// needs to decide whether a file changed since the last run.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Prefix is the key prefix under which manifest entries are stored.
+const Prefix = "manifest/"
+
+// Entry is the recorded state of a single indexed file.
+type Entry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Mtime  int64  `json:"mtime"`
+	Sha256 string `json:"sha256"`
+	DocID  string `json:"doc_id"`
+}
+
+// Key returns the storage key for the manifest entry of path.
+func Key(path string) []byte {
+	return []byte(Prefix + path)
+}
+
+// Encode serializes an entry for storage.
+func Encode(entry *Entry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest entry for %s: %v", entry.Path, err)
+	}
+	return data, nil
+}
+
+// Decode deserializes a previously stored entry.
+func Decode(data []byte) (*Entry, error) {
+	entry := &Entry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest entry: %v", err)
+	}
+	return entry, nil
+}
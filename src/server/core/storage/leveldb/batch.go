@@ -0,0 +1,38 @@
+package leveldb
+
+import (
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Batch accumulates writes to be committed to the database atomically.
+type Batch struct {
+	db    *DB
+	batch *leveldb.Batch
+}
+
+// Put stages a key-value write.
+func (b *Batch) Put(key, value []byte) {
+	b.batch.Put(key, value)
+}
+
+// Delete stages a key removal.
+func (b *Batch) Delete(key []byte) {
+	b.batch.Delete(key)
+}
+
+// Commit atomically applies all staged operations to the database.
+func (b *Batch) Commit() error {
+	b.db.mutex.Lock()
+	defer b.db.mutex.Unlock()
+
+	if b.db.closed {
+		return fmt.Errorf("database is closed")
+	}
+
+	if err := b.db.db.Write(b.batch, nil); err != nil {
+		return fmt.Errorf("failed to commit batch: %v", err)
+	}
+	return nil
+}
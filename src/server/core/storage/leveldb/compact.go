@@ -0,0 +1,23 @@
+package leveldb
+
+import (
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// CompactRange compacts the given key range, reclaiming space left by
+// deleted keys without needing to restart the process.
+func (d *DB) CompactRange(r util.Range) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return fmt.Errorf("database is closed")
+	}
+
+	if err := d.db.CompactRange(r); err != nil {
+		return fmt.Errorf("failed to compact range: %v", err)
+	}
+	return nil
+}
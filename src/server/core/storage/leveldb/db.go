@@ -6,7 +6,6 @@ import (
 	"sync"
 
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 // DB represents a LevelDB database instance
@@ -18,6 +17,8 @@ type DB struct {
 	snap        *leveldb.Snapshot // A snapshot of the database to allow concurrent read operations
 	activeSnaps map[*leveldb.Snapshot]int
 	mutex       sync.RWMutex
+
+	walSeq uint64 // monotonic counter ordering WAL records, see wal.go
 }
 
 // OpenDB opens a LevelDB database at the specified path
@@ -47,6 +48,11 @@ func OpenDB(path string) (*DB, error) {
 		return nil, err
 	}
 
+	if err := ldb.restoreWALSeq(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return ldb, nil
 }
 
@@ -191,37 +197,11 @@ func (d *DB) Batch() *Batch {
 	}
 }
 
-// Scan performs a range scan over the database
+// Scan performs a range scan over the database. It is kept for callers that
+// want the whole result set at once; NewIterator should be preferred for
+// large ranges since Scan materializes every match in memory.
 func (d *DB) Scan(prefix []byte, limit int) ([][2][]byte, error) {
-	d.mutex.RLock()
-	defer d.mutex.RUnlock()
-
-	if d.closed {
-		return nil, fmt.Errorf("database is closed")
-	}
-
-	// Always use the DB directly, not the snapshot
-	iter := d.db.NewIterator(util.BytesPrefix(prefix), nil)
-	defer iter.Release()
-
-	var results [][2][]byte
-	for iter.Next() {
-		if limit > 0 && len(results) >= limit {
-			break
-		}
-
-		key := make([]byte, len(iter.Key()))
-		value := make([]byte, len(iter.Value()))
-		copy(key, iter.Key())
-		copy(value, iter.Value())
-		results = append(results, [2][]byte{key, value})
-	}
-
-	if err := iter.Error(); err != nil {
-		return nil, fmt.Errorf("scan failed: %v", err)
-	}
-
-	return results, nil
+	return drain(d.NewIterator(prefix, nil), limit)
 }
 
 func (d *DB) releaseSnapInternal(snap *leveldb.Snapshot) {
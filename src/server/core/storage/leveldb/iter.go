@@ -0,0 +1,148 @@
+package leveldb
+
+import (
+	"fmt"
+
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// IterOptions controls how a Iter traverses its key range.
+type IterOptions struct {
+	// Reverse positions the iterator at the end of the range so the first
+	// call to Next behaves like iterating backwards from the last key.
+	Reverse bool
+}
+
+// Iter streams key/value pairs under a prefix without materializing them
+// all in memory, unlike Scan. An Iter from DB.NewIterator holds its
+// snapshot's ref-count for its whole lifetime, so results stay consistent
+// until Release is called; one from Snap.NewIterator relies on the Snap's
+// own ref and holds none of its own.
+type Iter struct {
+	iter    iterator.Iterator
+	release func()
+	err     error
+	reverse bool // Next steps backwards, see IterOptions.Reverse
+	started bool // set once the first Next call has positioned the iterator
+}
+
+func newIter(snap *goleveldb.Snapshot, prefix []byte, opts *IterOptions, release func()) *Iter {
+	it := snap.NewIterator(util.BytesPrefix(prefix), nil)
+	reverse := opts != nil && opts.Reverse
+	if reverse {
+		it.Last()
+	}
+	return &Iter{iter: it, release: release, reverse: reverse}
+}
+
+// NewIterator returns a streaming iterator over keys sharing prefix, backed
+// by the database's current snapshot. The returned Iter must be released.
+func (d *DB) NewIterator(prefix []byte, opts *IterOptions) *Iter {
+	snap, release := d.GetSnapshot()
+	if snap == nil {
+		return &Iter{err: fmt.Errorf("database is closed")}
+	}
+	return newIter(snap.snap, prefix, opts, release)
+}
+
+// NewIterator returns a streaming iterator over keys sharing prefix, backed
+// by this already-acquired snapshot.
+func (s *Snap) NewIterator(prefix []byte, opts *IterOptions) *Iter {
+	return newIter(s.snap, prefix, opts, func() {})
+}
+
+// Next advances the iterator, backwards if it was constructed with
+// IterOptions.Reverse. It returns false once the range is exhausted or an
+// error occurred; check Error afterwards to tell the two apart.
+func (it *Iter) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	// it.Last() already positioned a reverse iterator on its first entry,
+	// so the first Next call just confirms that position; only later
+	// calls should actually step.
+	if it.reverse {
+		if !it.started {
+			it.started = true
+			return it.iter.Valid()
+		}
+		return it.iter.Prev()
+	}
+
+	return it.iter.Next()
+}
+
+// Prev moves the iterator backwards. See Next for the return convention.
+func (it *Iter) Prev() bool {
+	if it.err != nil {
+		return false
+	}
+	return it.iter.Prev()
+}
+
+// Seek moves the iterator to the first key >= key within its range.
+func (it *Iter) Seek(key []byte) bool {
+	if it.err != nil {
+		return false
+	}
+	return it.iter.Seek(key)
+}
+
+// Key returns a copy of the current key, safe to retain after Next/Release.
+func (it *Iter) Key() []byte {
+	key := it.iter.Key()
+	out := make([]byte, len(key))
+	copy(out, key)
+	return out
+}
+
+// Value returns a copy of the current value, safe to retain after
+// Next/Release.
+func (it *Iter) Value() []byte {
+	value := it.iter.Value()
+	out := make([]byte, len(value))
+	copy(out, value)
+	return out
+}
+
+// Error reports any error encountered during iteration.
+func (it *Iter) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.iter.Error()
+}
+
+// Release frees the underlying iterator and, if this Iter owns a snapshot
+// ref, releases it too. Safe to call on a zero-value-failed Iter.
+func (it *Iter) Release() {
+	if it.iter != nil {
+		it.iter.Release()
+	}
+	if it.release != nil {
+		it.release()
+	}
+}
+
+// drain materializes up to limit key/value pairs from it, closing it when
+// done. limit <= 0 means unlimited. It exists to back the legacy Scan API
+// on top of the streaming iterator.
+func drain(it *Iter, limit int) ([][2][]byte, error) {
+	defer it.Release()
+
+	var results [][2][]byte
+	for it.Next() {
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+		results = append(results, [2][]byte{it.Key(), it.Value()})
+	}
+
+	if err := it.Error(); err != nil {
+		return nil, fmt.Errorf("scan failed: %v", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package leveldb
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// idleTimeout is how long a workspace's DB stays open with no active
+// Acquires before Manager closes it, to avoid leaking file descriptors and
+// holding the LevelDB lock on workspaces nobody is using.
+const idleTimeout = 10 * time.Minute
+
+type managedDB struct {
+	db       *DB
+	refs     int
+	idleTime *time.Timer
+}
+
+// Manager maps a workspace key to a shared *DB, opening it lazily on first
+// Acquire, reference-counting concurrent users, and closing it again once
+// it has sat idle for idleTimeout.
+type Manager struct {
+	baseDir string
+
+	mutex   sync.Mutex
+	entries map[string]*managedDB
+}
+
+// NewManager creates a Manager whose workspace databases live under
+// baseDir/<key>.
+func NewManager(baseDir string) *Manager {
+	return &Manager{
+		baseDir: baseDir,
+		entries: make(map[string]*managedDB),
+	}
+}
+
+// Acquire returns the shared DB for key, opening it if it isn't already
+// open (or was closed after sitting idle). The caller must invoke the
+// returned release func once done with the DB.
+func (m *Manager) Acquire(key string) (*DB, func(), error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || e.db.IsClosed() {
+		db, err := OpenDB(filepath.Join(m.baseDir, key))
+		if err != nil {
+			return nil, nil, err
+		}
+		e = &managedDB{db: db}
+		m.entries[key] = e
+	}
+
+	if e.idleTime != nil {
+		e.idleTime.Stop()
+		e.idleTime = nil
+	}
+	e.refs++
+
+	var releaseOnce sync.Once
+	release := func() {
+		releaseOnce.Do(func() {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			e.refs--
+			if e.refs <= 0 {
+				m.scheduleIdleClose(key, e)
+			}
+		})
+	}
+
+	return e.db, release, nil
+}
+
+// scheduleIdleClose arms a timer that closes e's DB after idleTimeout,
+// unless it gets re-acquired or superseded first. Callers must hold
+// m.mutex.
+func (m *Manager) scheduleIdleClose(key string, e *managedDB) {
+	e.idleTime = time.AfterFunc(idleTimeout, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		if e.refs > 0 || m.entries[key] != e {
+			return
+		}
+		if err := e.db.Close(); err != nil {
+			// The DB is still open underneath (e.g. a reader is still
+			// pinning a snapshot); keep the entry so the next Acquire
+			// reuses it instead of hitting the LevelDB file lock, and
+			// try closing it again after another idle period.
+			m.scheduleIdleClose(key, e)
+			return
+		}
+		delete(m.entries, key)
+	})
+}
@@ -0,0 +1,36 @@
+package leveldb
+
+// Reader is a per-request handle onto a single, consistent snapshot of the
+// database. Every Get/Scan/Iter call made through the same Reader observes
+// the same point in time, even while the indexer keeps writing.
+type Reader struct {
+	snap *Snap
+}
+
+// NewReader acquires the database's current snapshot for the lifetime of a
+// single logical read (e.g. one query request). The caller must invoke the
+// returned release func once done with the Reader.
+func (d *DB) NewReader() (*Reader, func()) {
+	snap, release := d.GetSnapshot()
+	if snap == nil {
+		return nil, func() {}
+	}
+	return &Reader{snap: snap}, release
+}
+
+// Get retrieves the value for a key as of the reader's snapshot.
+func (r *Reader) Get(key []byte) ([]byte, error) {
+	return r.snap.Get(key)
+}
+
+// Scan performs a range scan over the reader's snapshot.
+func (r *Reader) Scan(prefix []byte, limit int) ([][2][]byte, error) {
+	return r.snap.Scan(prefix, limit)
+}
+
+// Iter returns a streaming iterator scoped to the reader's snapshot and key
+// prefix, for callers that need to stream results rather than materialize
+// them via Scan.
+func (r *Reader) Iter(prefix []byte) *Iter {
+	return r.snap.NewIterator(prefix, nil)
+}
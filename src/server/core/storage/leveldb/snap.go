@@ -0,0 +1,32 @@
+package leveldb
+
+import (
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Snap is a reference-counted handle onto a point-in-time view of the
+// database, obtained via DB.GetSnapshot. Its reads never observe writes
+// made after the snapshot was taken.
+type Snap struct {
+	db   *DB
+	snap *leveldb.Snapshot
+}
+
+// Get retrieves the value for a key as of the snapshot.
+func (s *Snap) Get(key []byte) ([]byte, error) {
+	value, err := s.snap.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data: %v", err)
+	}
+	return value, nil
+}
+
+// Scan performs a range scan over the database as of the snapshot.
+func (s *Snap) Scan(prefix []byte, limit int) ([][2][]byte, error) {
+	return drain(s.NewIterator(prefix, nil), limit)
+}
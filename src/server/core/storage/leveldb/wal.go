@@ -0,0 +1,93 @@
+package leveldb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// walPrefix keys the write-ahead log so it never collides with regular
+// entries and can be scanned/truncated in one pass.
+const walPrefix = "wal/"
+
+func walKey(seq uint64) []byte {
+	key := make([]byte, len(walPrefix)+8)
+	copy(key, walPrefix)
+	binary.BigEndian.PutUint64(key[len(walPrefix):], seq)
+	return key
+}
+
+// AppendLog appends a pre-encoded WAL record under the next monotonic key,
+// syncing to disk so the write survives a crash immediately after it
+// returns. Records are caller-defined byte blobs; see package wal for the
+// {run_id, op_type, payload, crc32} framing used by the indexer.
+func (d *DB) AppendLog(record []byte) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return fmt.Errorf("database is closed")
+	}
+
+	seq := atomic.AddUint64(&d.walSeq, 1)
+	if err := d.db.Put(walKey(seq), record, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("failed to append wal record: %v", err)
+	}
+	return nil
+}
+
+// restoreWALSeq seeds walSeq from the highest existing WAL key so that a
+// reopened DB keeps appending after any records a previous, unfinished run
+// already wrote, instead of reusing their keys. Called once from OpenDB,
+// before any concurrent writers exist.
+func (d *DB) restoreWALSeq() error {
+	iter := d.db.NewIterator(util.BytesPrefix([]byte(walPrefix)), nil)
+	defer iter.Release()
+
+	if iter.Last() {
+		seq := binary.BigEndian.Uint64(iter.Key()[len(walPrefix):])
+		atomic.StoreUint64(&d.walSeq, seq)
+	}
+	return iter.Error()
+}
+
+// ReplayLog calls fn with every WAL record still on disk, in the order they
+// were appended. It is used on startup to detect and resume an interrupted
+// run.
+func (d *DB) ReplayLog(fn func(record []byte) error) error {
+	iter := d.NewIterator([]byte(walPrefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if err := fn(iter.Value()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// TruncateLog removes every WAL record in a single batch, marking the
+// current run as committed. It takes a fresh snapshot first so records
+// AppendLog wrote to the live DB just before this call (e.g. the final
+// run_commit) are actually seen and removed, instead of surviving under a
+// snapshot that predates them.
+func (d *DB) TruncateLog() error {
+	if err := d.TakeSnapshot(); err != nil {
+		return err
+	}
+
+	iter := d.NewIterator([]byte(walPrefix), nil)
+	defer iter.Release()
+
+	batch := d.Batch()
+	for iter.Next() {
+		batch.Delete(iter.Key())
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to scan wal: %v", err)
+	}
+	return batch.Commit()
+}
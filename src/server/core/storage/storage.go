@@ -0,0 +1,379 @@
+// Package storage is the indexer's durable store: parsed documents, the
+// per-file manifest used for incremental reindexing, and the write-ahead
+// log used to resume an interrupted run. Each workspace gets its own
+// isolated Index, handed out by a Manager so several workspaces can be
+// indexed in one process without leaking file descriptors.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"search-indexer/server/core/document"
+	"search-indexer/server/core/manifest"
+	"search-indexer/server/core/storage/leveldb"
+	"search-indexer/server/core/wal"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	docPrefix     = "doc/"
+	postingPrefix = "posting/"
+)
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// postingKey builds the key under which a (word, docID) posting is stored.
+// The NUL separator can't appear in hex-encoded doc IDs. Words come from
+// strings.Fields, which keeps NUL bytes, so a word containing NUL can make
+// a prefix search for a shorter word also match its postings.
+func postingKey(word, docID string) []byte {
+	return []byte(postingPrefix + word + "\x00" + docID)
+}
+
+// Index is the durable store for a single workspace.
+type Index struct {
+	db *leveldb.DB
+}
+
+// Manager hands out a shared, reference-counted Index per workspace,
+// opening its LevelDB lazily and closing it again once it sits idle.
+type Manager struct {
+	m *leveldb.Manager
+}
+
+// NewManager creates a Manager whose workspace indexes live under
+// baseDir/<workspace-name>.
+func NewManager(baseDir string) *Manager {
+	return &Manager{m: leveldb.NewManager(baseDir)}
+}
+
+// Acquire returns the shared Index for a workspace, opening it if needed.
+// The caller must invoke the returned release func once done with it.
+func (m *Manager) Acquire(workspace string) (*Index, func(), error) {
+	db, release, err := m.m.Acquire(workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Index{db: db}, release, nil
+}
+
+// Save persists the parsed documents to the index. The shard argument is not
+// used: every document is stored under the same doc/ and posting/ prefixes.
+func (idx *Index) Save(docs []*document.Document, shard string) error {
+	batch := idx.db.Batch()
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		// A re-indexed file keeps its docID, so any postings from its
+		// previous content must be cleared first or words it no longer
+		// contains would still resolve to it in searches.
+		if err := idx.clearPostings(batch, doc.ID); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document %s: %v", doc.Path, err)
+		}
+		batch.Put([]byte(docPrefix+doc.ID), data)
+		emitPostings(batch, doc)
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+
+	// Advance the read snapshot in bounded steps rather than pinning it for
+	// the whole run, so in-flight readers see this batch without blocking
+	// the next one.
+	return idx.db.TakeSnapshot()
+}
+
+// emitPostings stages one posting per distinct word in doc's content.
+func emitPostings(batch *leveldb.Batch, doc *document.Document) {
+	seen := map[string]bool{}
+	for _, word := range doc.Content.Words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		batch.Put(postingKey(word, doc.ID), []byte(doc.ID))
+	}
+}
+
+// clearPostings stages a delete for every posting currently on record for
+// docID, based on the words in its previously-saved document, if any. It
+// must run before the doc's new content (or its removal) is committed.
+func (idx *Index) clearPostings(batch *leveldb.Batch, docID string) error {
+	data, err := idx.db.Get([]byte(docPrefix + docID))
+	if err != nil {
+		return fmt.Errorf("failed to load document %s: %v", docID, err)
+	}
+	if data == nil {
+		return nil
+	}
+
+	doc := &document.Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return fmt.Errorf("failed to decode document %s: %v", docID, err)
+	}
+
+	seen := map[string]bool{}
+	for _, word := range doc.Content.Words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		batch.Delete(postingKey(word, docID))
+	}
+	return nil
+}
+
+// Search returns the IDs of every document whose content contains word, as
+// of a single snapshot, so a query never sees a half-applied write.
+func (idx *Index) Search(word string) ([]string, error) {
+	reader, release := idx.db.NewReader()
+	defer release()
+
+	iter := reader.Iter(postingKey(word, ""))
+	defer iter.Release()
+
+	var docIDs []string
+	for iter.Next() {
+		docIDs = append(docIDs, string(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to search %q: %v", word, err)
+	}
+	return docIDs, nil
+}
+
+// LoadManifest returns the recorded manifest entry for path, or nil if no
+// entry has been recorded yet.
+func (idx *Index) LoadManifest(path string) (*manifest.Entry, error) {
+	data, err := idx.db.Get(manifest.Key(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest for %s: %v", path, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return manifest.Decode(data)
+}
+
+// SaveManifest records, or refreshes, the manifest entry for an indexed file.
+func (idx *Index) SaveManifest(entry *manifest.Entry) error {
+	data, err := manifest.Encode(entry)
+	if err != nil {
+		return err
+	}
+	return idx.db.Put(manifest.Key(entry.Path), data)
+}
+
+// ListManifest returns every manifest entry currently on record. It reads
+// the entries through a streaming iterator, but returns them all in one
+// slice, so the whole manifest is held in memory at once.
+func (idx *Index) ListManifest() ([]*manifest.Entry, error) {
+	iter := idx.db.NewIterator([]byte(manifest.Prefix), nil)
+	defer iter.Release()
+
+	var entries []*manifest.Entry
+	for iter.Next() {
+		entry, err := manifest.Decode(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to scan manifest: %v", err)
+	}
+	return entries, nil
+}
+
+// DeleteDocs removes the indexed documents, their postings, and the
+// manifest entries for files that disappeared since the previous run, in a
+// single atomic batch.
+func (idx *Index) DeleteDocs(entries []*manifest.Entry) error {
+	batch := idx.db.Batch()
+	for _, entry := range entries {
+		if err := idx.clearPostings(batch, entry.DocID); err != nil {
+			return err
+		}
+		batch.Delete([]byte(docPrefix + entry.DocID))
+		batch.Delete(manifest.Key(entry.Path))
+	}
+	return batch.Commit()
+}
+
+// AppendWAL records a single write-ahead-log entry for the given run, so an
+// interrupted run can be resumed on the next startup.
+func (idx *Index) AppendWAL(runID string, op wal.Op, payload []byte) error {
+	return idx.db.AppendLog(wal.Encode(&wal.Record{RunID: runID, OpType: op, Payload: payload}))
+}
+
+// ReplayWAL invokes fn with every WAL record left over from an interrupted
+// run, in the order they were appended.
+func (idx *Index) ReplayWAL(fn func(*wal.Record) error) error {
+	return idx.db.ReplayLog(func(data []byte) error {
+		record, err := wal.Decode(data)
+		if err != nil {
+			return err
+		}
+		return fn(record)
+	})
+}
+
+// TruncateWAL clears the write-ahead log, marking the current run as
+// committed.
+func (idx *Index) TruncateWAL() error {
+	return idx.db.TruncateLog()
+}
+
+// CheckReport summarizes the result of Check.
+type CheckReport struct {
+	ManifestEntries  int
+	OrphanedDocs     []string // doc IDs with no matching manifest entry
+	OrphanedPostings []string // posting keys pointing at a missing doc
+	HashMismatches   []string // paths whose on-disk content no longer matches the recorded hash
+}
+
+// Check cross-checks the manifest, forward-doc store, and posting store
+// against each other, reporting docs with no manifest entry and postings
+// whose doc is not in the forward-doc store; a manifest entry whose doc is
+// missing from the forward-doc store is not reported. It reads through a single
+// snapshot handle so it can run safely while the indexer keeps writing. When
+// readData is true it also re-hashes each manifest entry's file on disk.
+func (idx *Index) Check(readData bool) (*CheckReport, error) {
+	reader, release := idx.db.NewReader()
+	defer release()
+
+	report := &CheckReport{}
+	docIDs := map[string]bool{}
+
+	manifestIter := reader.Iter([]byte(manifest.Prefix))
+	for manifestIter.Next() {
+		entry, err := manifest.Decode(manifestIter.Value())
+		if err != nil {
+			manifestIter.Release()
+			return nil, err
+		}
+		report.ManifestEntries++
+		docIDs[entry.DocID] = true
+
+		if readData {
+			data, err := os.ReadFile(entry.Path)
+			if err != nil || hashBytes(data) != entry.Sha256 {
+				report.HashMismatches = append(report.HashMismatches, entry.Path)
+			}
+		}
+	}
+	if err := manifestIter.Error(); err != nil {
+		manifestIter.Release()
+		return nil, fmt.Errorf("failed to scan manifest: %v", err)
+	}
+	manifestIter.Release()
+
+	storedDocs := map[string]bool{}
+	docIter := reader.Iter([]byte(docPrefix))
+	for docIter.Next() {
+		id := strings.TrimPrefix(string(docIter.Key()), docPrefix)
+		storedDocs[id] = true
+		if !docIDs[id] {
+			report.OrphanedDocs = append(report.OrphanedDocs, id)
+		}
+	}
+	if err := docIter.Error(); err != nil {
+		docIter.Release()
+		return nil, fmt.Errorf("failed to scan docs: %v", err)
+	}
+	docIter.Release()
+
+	postingIter := reader.Iter([]byte(postingPrefix))
+	for postingIter.Next() {
+		if !storedDocs[string(postingIter.Value())] {
+			report.OrphanedPostings = append(report.OrphanedPostings, string(postingIter.Key()))
+		}
+	}
+	if err := postingIter.Error(); err != nil {
+		postingIter.Release()
+		return nil, fmt.Errorf("failed to scan postings: %v", err)
+	}
+	postingIter.Release()
+
+	return report, nil
+}
+
+// Rebuild drops every posting and re-derives them from the forward-doc
+// store, batchSize docs per commit. Use it to repair the orphaned postings
+// Check reports.
+func (idx *Index) Rebuild(batchSize int) error {
+	if err := idx.dropPrefix(postingPrefix); err != nil {
+		return err
+	}
+
+	iter := idx.db.NewIterator([]byte(docPrefix), nil)
+	defer iter.Release()
+
+	batch := idx.db.Batch()
+	pending := 0
+	for iter.Next() {
+		doc := &document.Document{}
+		if err := json.Unmarshal(iter.Value(), doc); err != nil {
+			return fmt.Errorf("failed to decode document: %v", err)
+		}
+		emitPostings(batch, doc)
+
+		pending++
+		if pending >= batchSize {
+			if err := batch.Commit(); err != nil {
+				return err
+			}
+			batch = idx.db.Batch()
+			pending = 0
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to scan docs: %v", err)
+	}
+	if pending > 0 {
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+	}
+	return idx.db.TakeSnapshot()
+}
+
+func (idx *Index) dropPrefix(prefix string) error {
+	iter := idx.db.NewIterator([]byte(prefix), nil)
+	defer iter.Release()
+
+	batch := idx.db.Batch()
+	for iter.Next() {
+		batch.Delete(iter.Key())
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to scan %s: %v", prefix, err)
+	}
+	return batch.Commit()
+}
+
+// Compact reclaims space left behind by deletes across every logical key
+// range, without needing to bounce the process.
+func (idx *Index) Compact() error {
+	for _, prefix := range []string{manifest.Prefix, docPrefix, postingPrefix, wal.Prefix} {
+		if err := idx.db.CompactRange(*util.BytesPrefix([]byte(prefix))); err != nil {
+			return fmt.Errorf("failed to compact %s: %v", prefix, err)
+		}
+	}
+	return nil
+}
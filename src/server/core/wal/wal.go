@@ -0,0 +1,137 @@
+// Package wal defines the record framing the indexer uses to make a run
+// resumable after a crash: a run_start marks the file list a run intends to
+// process, a file_done marks each file as it completes, and a run_commit
+// closes the run out so the next startup doesn't try to resume it.
+package wal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+)
+
+// Prefix is the key prefix leveldb.DB stores WAL records under; exported so
+// operational tooling (e.g. compact) can address the WAL as a logical
+// range without duplicating the literal.
+const Prefix = "wal/"
+
+// Op identifies the kind of WAL record.
+type Op string
+
+const (
+	OpRunStart  Op = "run_start"
+	OpFileDone  Op = "file_done"
+	OpRunCommit Op = "run_commit"
+)
+
+// RunStart is the payload of an OpRunStart record.
+type RunStart struct {
+	BaseDirHash string   `json:"base_dir_hash"`
+	Files       []string `json:"files"`
+}
+
+// FileDone is the payload of an OpFileDone record.
+type FileDone struct {
+	Path  string `json:"path"`
+	DocID string `json:"doc_id"`
+}
+
+// Record is a single WAL entry. Encode writes it as the length-prefixed
+// run_id, op_type and payload, followed by a crc32 of the payload.
+type Record struct {
+	RunID   string
+	OpType  Op
+	Payload []byte
+}
+
+// Encode serializes a record for DB.AppendLog.
+func Encode(r *Record) []byte {
+	out := appendLenPrefixed(nil, []byte(r.RunID))
+	out = appendLenPrefixed(out, []byte(r.OpType))
+	out = appendLenPrefixed(out, r.Payload)
+
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(r.Payload))
+	return append(out, crc[:]...)
+}
+
+// Decode parses a record previously produced by Encode, verifying the
+// payload's checksum.
+func Decode(data []byte) (*Record, error) {
+	runID, rest, err := readLenPrefixed(data)
+	if err != nil {
+		return nil, err
+	}
+	opType, rest, err := readLenPrefixed(rest)
+	if err != nil {
+		return nil, err
+	}
+	payload, rest, err := readLenPrefixed(rest)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 4 {
+		return nil, fmt.Errorf("malformed wal record: missing checksum")
+	}
+	if binary.BigEndian.Uint32(rest) != crc32.ChecksumIEEE(payload) {
+		return nil, fmt.Errorf("wal record checksum mismatch")
+	}
+
+	return &Record{RunID: string(runID), OpType: Op(opType), Payload: payload}, nil
+}
+
+func appendLenPrefixed(dst, data []byte) []byte {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	dst = append(dst, length[:]...)
+	return append(dst, data...)
+}
+
+func readLenPrefixed(data []byte) (value, rest []byte, err error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("malformed wal record: truncated length")
+	}
+	length := binary.BigEndian.Uint32(data[:4])
+	data = data[4:]
+	if uint32(len(data)) < length {
+		return nil, nil, fmt.Errorf("malformed wal record: truncated payload")
+	}
+	return data[:length], data[length:], nil
+}
+
+// EncodeRunStart marshals a RunStart for use as a Record's Payload.
+func EncodeRunStart(r *RunStart) ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode run_start payload: %v", err)
+	}
+	return data, nil
+}
+
+// DecodeRunStart unmarshals a run_start Record's Payload.
+func DecodeRunStart(data []byte) (*RunStart, error) {
+	r := &RunStart{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to decode run_start payload: %v", err)
+	}
+	return r, nil
+}
+
+// EncodeFileDone marshals a FileDone for use as a Record's Payload.
+func EncodeFileDone(f *FileDone) ([]byte, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode file_done payload: %v", err)
+	}
+	return data, nil
+}
+
+// DecodeFileDone unmarshals a file_done Record's Payload.
+func DecodeFileDone(data []byte) (*FileDone, error) {
+	f := &FileDone{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to decode file_done payload: %v", err)
+	}
+	return f, nil
+}
@@ -1,18 +1,47 @@
 package indexer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"search-indexer/running"
 	"search-indexer/server/conf"
 	"search-indexer/server/core/document"
+	"search-indexer/server/core/manifest"
 	"search-indexer/server/core/storage"
+	"search-indexer/server/core/wal"
 	"search-indexer/utils"
 	fsutils "search-indexer/utils/fs"
 	gitutils "search-indexer/utils/git"
+	"sync"
 	"time"
 )
 
+var forceFull = flag.Bool("force-full", false, "bypass the manifest and reparse every file")
+
+var (
+	managerOnce sync.Once
+	manager     *storage.Manager
+)
+
+func getManager() *storage.Manager {
+	managerOnce.Do(func() {
+		manager = storage.NewManager(filepath.Join(running.RootPath(), "data", "index"))
+	})
+	return manager
+}
+
+// Manager returns the shared, workspace-keyed storage Manager the indexer
+// writes through, so the HTTP query handlers can read the same Index
+// through server/query without opening a second LevelDB instance.
+func Manager() *storage.Manager {
+	return getManager()
+}
+
 type GitIgnoreFilter struct {
 	ignore *gitutils.GitIgnore
 }
@@ -22,28 +51,49 @@ func (f *GitIgnoreFilter) Match(path string, isDir bool) bool {
 }
 
 func demo() {
-	conf := conf.Get()
-	baseDir := conf.ForTest.Path
+	workspaces := conf.Get().Workspaces
+	if len(workspaces) == 0 {
+		log.Println("no workspaces configured")
+		return
+	}
+
+	for _, ws := range workspaces {
+		if running.IsShuttingDown() {
+			return
+		}
+		indexWorkspace(ws)
+	}
+}
+
+func indexWorkspace(ws conf.Workspace) {
+	baseDir := ws.Path
 	if baseDir == "" {
-		log.Println("ForTest.Path is not set")
+		log.Println("workspace", ws.Name, "has no path set")
+		return
+	}
+
+	idx, release, err := getManager().Acquire(ws.Name)
+	if err != nil {
+		log.Println("Error acquiring index for workspace", ws.Name, ":", err)
 		return
 	}
+	defer release()
 
-	log.Println("Indexing:", baseDir)
+	log.Println("Indexing workspace", ws.Name, "-", baseDir)
 
 	var filter fsutils.ListFileFilter
-	if conf.Filters.Exclude.UseGitIgnore {
+	if ws.Filters.Exclude.UseGitIgnore {
 		log.Println("Using gitignore filter")
 		filter = &GitIgnoreFilter{
 			ignore: gitutils.NewGitIgnore(baseDir),
 		}
 	} else {
 		log.Println("Using customized filter")
-		filter = utils.NewSimpleFilterExclude(conf.Filters.Exclude.Customized, baseDir)
+		filter = utils.NewSimpleFilterExclude(ws.Filters.Exclude.Customized, baseDir)
 	}
 
 	files, err := fsutils.ListFiles(baseDir, fsutils.ListFileOptions{
-		Filter: utils.NewSimpleFilterExclude(conf.Filters.Exclude.Customized, baseDir),
+		Filter: utils.NewSimpleFilterExclude(ws.Filters.Exclude.Customized, baseDir),
 	})
 
 	if err != nil {
@@ -53,7 +103,7 @@ func demo() {
 
 	log.Println(len(files), "files found.")
 
-	filter = utils.NewSimpleFilter(conf.Filters.Include, baseDir)
+	filter = utils.NewSimpleFilter(ws.Filters.Include, baseDir)
 	filteredFiles := []string{}
 	for _, file := range files {
 		if running.IsShuttingDown() {
@@ -68,39 +118,260 @@ func demo() {
 
 	log.Println(len(filteredFiles), "files matched.")
 
+	runID, resumed := startRun(idx, baseDir, filteredFiles)
+	if len(resumed) > 0 {
+		log.Println("Resuming interrupted run", runID, "-", len(resumed), "files already done")
+	}
+
 	succ := 0
 	faied := 0
+	skipped := 0
 	last := time.Now()
 	wordCount := 0
 	docs := []*document.Document{}
+	pending := []*pendingFile{}
+	visited := map[string]bool{}
 	for n, file := range filteredFiles {
 		if running.IsShuttingDown() {
 			return
 		}
+		visited[file] = true
 
-		doc, err := document.Parse(file, baseDir)
-		if err != nil {
+		if _, done := resumed[file]; done {
+			succ++
+			continue
+		}
+
+		doc, entry, changed := reuseOrParse(idx, file, baseDir, &skipped)
+		if doc == nil && !changed {
 			faied++
-		} else {
+			continue
+		}
+		if doc != nil {
 			succ++
 			wordCount += len(doc.Content.Words)
+			docs = append(docs, doc)
+			pending = append(pending, &pendingFile{path: file, entry: entry})
 		}
-		docs = append(docs, doc)
+
 		if len(docs) > 100 {
-			storage.Save(docs, "0")
+			commitBatch(idx, runID, docs, pending)
 			docs = []*document.Document{}
+			pending = []*pendingFile{}
 		}
 
 		if time.Since(last) > 200*time.Millisecond || n == len(filteredFiles)-1 {
 			last = time.Now()
-			log.Printf("Parsing progress %d / %d, succ: %d, failed, %d, wordCount: %d", n+1, len(filteredFiles), succ, faied, wordCount)
+			log.Printf("Parsing progress %d / %d, succ: %d, failed, %d, skipped: %d, wordCount: %d", n+1, len(filteredFiles), succ, faied, skipped, wordCount)
 		}
 	}
 
 	if len(docs) > 0 {
-		storage.Save(docs, "0")
+		commitBatch(idx, runID, docs, pending)
+	}
+
+	removeDeletedEntries(idx, visited)
+	commitRun(idx, runID)
+
+	log.Println(len(filteredFiles), "parsed files, succ:", succ, "failed:", faied, "skipped:", skipped, "wordCount:", wordCount)
+
+}
+
+// pendingFile is a parsed-but-not-yet-committed document: its manifest entry
+// must only be saved, and its file_done WAL record only written, once the
+// covering idx.Save has durably committed the document itself.
+type pendingFile struct {
+	path  string
+	entry *manifest.Entry
+}
+
+// commitBatch persists docs, then records each one's manifest entry and
+// file_done WAL record, in that order, so a crash mid-batch never leaves a
+// file marked done without its document actually saved.
+func commitBatch(idx *storage.Index, runID string, docs []*document.Document, pending []*pendingFile) {
+	if err := idx.Save(docs, "0"); err != nil {
+		log.Println("Error saving documents:", err)
+		return
+	}
+
+	for i, doc := range docs {
+		p := pending[i]
+
+		if err := idx.SaveManifest(p.entry); err != nil {
+			log.Println("Error saving manifest for", p.path, ":", err)
+		}
+
+		payload, err := wal.EncodeFileDone(&wal.FileDone{Path: p.path, DocID: doc.ID})
+		if err != nil {
+			log.Println("Error encoding file_done:", err)
+			continue
+		}
+		if err := idx.AppendWAL(runID, wal.OpFileDone, payload); err != nil {
+			log.Println("Error writing file_done:", err)
+		}
+	}
+}
+
+// startRun looks for a run_start WAL record left by an interrupted previous
+// run targeting the same baseDir. If found, it returns that run's ID and the
+// set of files it had already finished, so the caller can skip them. If not
+// found (or it targeted a different baseDir), it starts a new run and
+// records a run_start for it.
+func startRun(idx *storage.Index, baseDir string, files []string) (runID string, resumed map[string]string) {
+	var prevRun *wal.RunStart
+	resumed = map[string]string{}
+
+	err := idx.ReplayWAL(func(r *wal.Record) error {
+		switch r.OpType {
+		case wal.OpRunStart:
+			rs, err := wal.DecodeRunStart(r.Payload)
+			if err != nil {
+				return err
+			}
+			prevRun = rs
+			runID = r.RunID
+			resumed = map[string]string{}
+		case wal.OpFileDone:
+			fd, err := wal.DecodeFileDone(r.Payload)
+			if err != nil {
+				return err
+			}
+			resumed[fd.Path] = fd.DocID
+		case wal.OpRunCommit:
+			// The run finished cleanly, so there is nothing to resume.
+			prevRun = nil
+			resumed = map[string]string{}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error replaying wal, starting fresh:", err)
+		prevRun = nil
+	}
+
+	baseDirHash := hashString(baseDir)
+	if prevRun != nil && prevRun.BaseDirHash == baseDirHash {
+		return runID, resumed
+	}
+
+	runID = newRunID()
+	payload, err := wal.EncodeRunStart(&wal.RunStart{BaseDirHash: baseDirHash, Files: files})
+	if err != nil {
+		log.Println("Error encoding run_start:", err)
+		return runID, map[string]string{}
+	}
+	if err := idx.AppendWAL(runID, wal.OpRunStart, payload); err != nil {
+		log.Println("Error writing run_start:", err)
+	}
+	return runID, map[string]string{}
+}
+
+// commitRun marks runID as cleanly finished so it won't be resumed on the
+// next startup.
+func commitRun(idx *storage.Index, runID string) {
+	if err := idx.AppendWAL(runID, wal.OpRunCommit, nil); err != nil {
+		log.Println("Error writing run_commit:", err)
+		return
+	}
+	if err := idx.TruncateWAL(); err != nil {
+		log.Println("Error truncating wal:", err)
+	}
+}
+
+func newRunID() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// reuseOrParse decides, based on the file's previous manifest entry,
+// whether it needs reparsing. It returns the parsed document when the file
+// was (re)indexed, or nil when the file was unchanged, skipped, or failed to
+// parse/stat (in which case changed reports whether it should count as a
+// skip rather than a failure). When a document is returned, its manifest
+// entry is returned too but not yet saved: the caller must not persist it
+// until the document itself is durably committed.
+func reuseOrParse(idx *storage.Index, file, baseDir string, skipped *int) (doc *document.Document, entry *manifest.Entry, changed bool) {
+	info, statErr := os.Stat(file)
+	if statErr != nil {
+		return nil, nil, false
+	}
+
+	var prev *manifest.Entry
+	if !*forceFull {
+		prev, _ = idx.LoadManifest(file)
+	}
+
+	mtime := info.ModTime().UnixNano()
+	if prev != nil && prev.Size == info.Size() && prev.Mtime == mtime {
+		*skipped++
+		return nil, nil, true
+	}
+
+	hash, hashErr := hashFile(file)
+	if prev != nil && hashErr == nil && hash == prev.Sha256 {
+		// Content is identical, and its document is already durably saved
+		// from a prior run; only mtime drifted, so it's safe to refresh the
+		// manifest entry immediately rather than deferring it.
+		idx.SaveManifest(&manifest.Entry{
+			Path:   file,
+			Size:   info.Size(),
+			Mtime:  mtime,
+			Sha256: hash,
+			DocID:  prev.DocID,
+		})
+		*skipped++
+		return nil, nil, true
+	}
+
+	parsed, err := document.Parse(file, baseDir)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return parsed, &manifest.Entry{
+		Path:   file,
+		Size:   info.Size(),
+		Mtime:  mtime,
+		Sha256: hash,
+		DocID:  parsed.ID,
+	}, true
+}
+
+// removeDeletedEntries drops manifest entries (and their indexed documents)
+// for files that were not visited during this run.
+func removeDeletedEntries(idx *storage.Index, visited map[string]bool) {
+	entries, err := idx.ListManifest()
+	if err != nil {
+		log.Println("Error loading manifest:", err)
+		return
+	}
+
+	var stale []*manifest.Entry
+	for _, entry := range entries {
+		if !visited[entry.Path] {
+			stale = append(stale, entry)
+		}
+	}
+	if len(stale) == 0 {
+		return
 	}
 
-	log.Println(len(filteredFiles), "parsed files, succ:", succ, "failed:", faied, "wordCount:", wordCount)
+	if err := idx.DeleteDocs(stale); err != nil {
+		log.Println("Error deleting stale docs:", err)
+		return
+	}
+	log.Println(len(stale), "deleted files removed from the index.")
+}
 
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
 }
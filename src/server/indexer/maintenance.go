@@ -0,0 +1,67 @@
+package indexer
+
+import (
+	"flag"
+	"log"
+
+	"search-indexer/server/conf"
+	"search-indexer/server/core/storage"
+)
+
+const rebuildBatchSize = 100
+
+var readData = flag.Bool("read-data", false, "when checking, also re-hash each file on disk against its recorded manifest hash")
+
+// Check walks every configured workspace's index, reporting orphaned docs,
+// orphaned postings, and (with --read-data) files whose content no longer
+// matches the hash recorded in the manifest. It can run while the server is
+// up, since it reads through a snapshot handle.
+func Check() {
+	forEachWorkspace(func(ws conf.Workspace, idx *storage.Index) {
+		report, err := idx.Check(*readData)
+		if err != nil {
+			log.Println("check failed for workspace", ws.Name, ":", err)
+			return
+		}
+
+		log.Printf("workspace %s: %d manifest entries, %d orphaned docs, %d orphaned postings, %d hash mismatches",
+			ws.Name, report.ManifestEntries, len(report.OrphanedDocs), len(report.OrphanedPostings), len(report.HashMismatches))
+	})
+}
+
+// RebuildIndex drops and re-derives postings for every configured
+// workspace from its forward-doc store, fixing whatever Check reported as
+// orphaned postings.
+func RebuildIndex() {
+	forEachWorkspace(func(ws conf.Workspace, idx *storage.Index) {
+		if err := idx.Rebuild(rebuildBatchSize); err != nil {
+			log.Println("rebuild-index failed for workspace", ws.Name, ":", err)
+			return
+		}
+		log.Println("rebuilt postings for workspace", ws.Name)
+	})
+}
+
+// Compact reclaims space left by deletes across every configured
+// workspace's index, without needing to restart the process.
+func Compact() {
+	forEachWorkspace(func(ws conf.Workspace, idx *storage.Index) {
+		if err := idx.Compact(); err != nil {
+			log.Println("compact failed for workspace", ws.Name, ":", err)
+			return
+		}
+		log.Println("compacted workspace", ws.Name)
+	})
+}
+
+func forEachWorkspace(fn func(conf.Workspace, *storage.Index)) {
+	for _, ws := range conf.Get().Workspaces {
+		idx, release, err := getManager().Acquire(ws.Name)
+		if err != nil {
+			log.Println("Error acquiring index for workspace", ws.Name, ":", err)
+			continue
+		}
+		fn(ws, idx)
+		release()
+	}
+}
@@ -0,0 +1,67 @@
+// Package query serves the indexer's search API: each HTTP request names a
+// workspace, acquires its Index from the shared Manager, and reads through
+// a single point-in-time snapshot so concurrent indexing can't produce a
+// half-updated result.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"search-indexer/server/conf"
+	"search-indexer/server/core/storage"
+)
+
+// Handler routes search requests to the right workspace's Index.
+type Handler struct {
+	manager *storage.Manager
+}
+
+// NewHandler returns a Handler backed by manager.
+func NewHandler(manager *storage.Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// Serve mounts the search handler on conf.Get().Port and blocks, serving
+// requests against manager until the listener errors; it does not stop on
+// its own when the process is shutting down. It is the entrypoint the server bootstrap should call
+// alongside the indexer's own demo/Check/RebuildIndex/Compact entrypoints.
+func Serve(manager *storage.Manager) error {
+	mux := http.NewServeMux()
+	mux.Handle("/search", NewHandler(manager))
+
+	addr := fmt.Sprintf(":%d", conf.Get().Port)
+	log.Println("query server listening on", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// ServeHTTP handles GET /search?workspace=<name>&word=<word>, returning the
+// JSON-encoded list of document IDs whose content contains word.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	workspace := r.URL.Query().Get("workspace")
+	word := r.URL.Query().Get("word")
+	if workspace == "" || word == "" {
+		http.Error(w, "workspace and word are required", http.StatusBadRequest)
+		return
+	}
+
+	idx, release, err := h.manager.Acquire(workspace)
+	if err != nil {
+		http.Error(w, "failed to acquire workspace: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer release()
+
+	docIDs, err := idx.Search(word)
+	if err != nil {
+		http.Error(w, "search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(docIDs); err != nil {
+		http.Error(w, "failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	}
+}